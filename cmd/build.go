@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+
+	"github.com/devspace-cloud/devspace/pkg/devspace/image"
+	logpkg "github.com/devspace-cloud/devspace/pkg/util/log"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// BuildCmd holds the flags for the "devspace build" command
+type BuildCmd struct {
+	Jobs       int
+	ForceBuild bool
+}
+
+// NewBuildCmd creates a new "devspace build" cobra command
+func NewBuildCmd() *cobra.Command {
+	cmd := &BuildCmd{}
+
+	buildCmd := &cobra.Command{
+		Use:   "build",
+		Short: "Builds all defined images",
+		Long: `
+#######################################################
+################## devspace build ####################
+#######################################################
+Builds all images that are defined in the devspace.yaml
+#######################################################
+	`,
+		RunE: cmd.Run,
+	}
+
+	buildCmd.Flags().IntVar(&cmd.Jobs, "jobs", -1, "Maximum number of images to build concurrently (unset uses build.jobs from the devspace config, 0 means runtime.NumCPU())")
+	buildCmd.Flags().BoolVar(&cmd.ForceBuild, "force-build", false, "Rebuild every image, ignoring the cache")
+
+	return buildCmd
+}
+
+// Run builds all images configured in the devspace.yaml, canceling any
+// in-flight builds on SIGINT/SIGTERM
+func (cmd *BuildCmd) Run(cobraCmd *cobra.Command, args []string) error {
+	log := logpkg.GetInstance()
+
+	client, err := kubeClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	_, err = image.BuildAll(ctx, client, false, cmd.ForceBuild, cmd.Jobs, log)
+	return err
+}
+
+// kubeClient builds a client for the currently active kube context, the same
+// way the other devspace commands do
+func kubeClient() (kubernetes.Interface, error) {
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(),
+		&clientcmd.ConfigOverrides{},
+	).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(config)
+}
@@ -0,0 +1,64 @@
+// Package latest defines the current devspace.yaml schema. Other packages
+// (image, image/buildah, ...) depend on this package's types rather than
+// parsing devspace.yaml themselves
+package latest
+
+// Config is the root of a parsed devspace.yaml
+type Config struct {
+	// Images contains the build configuration for every image, keyed by an
+	// arbitrary name used to cross-reference it elsewhere in the config
+	Images *map[string]*ImageConfig `yaml:"images,omitempty"`
+
+	// Build holds devspace-wide build settings that apply across all images,
+	// as opposed to ImageConfig.Build which is per-image
+	Build *BuildConfig `yaml:"build,omitempty"`
+}
+
+// BuildConfig holds devspace-wide build settings
+type BuildConfig struct {
+	// Jobs caps how many images BuildAll builds concurrently. It is
+	// overridden by the CLI's --jobs flag when set. 0 means runtime.NumCPU()
+	Jobs *int `yaml:"jobs,omitempty"`
+
+	// CacheVersion is mixed into every registry cache key, letting users
+	// globally invalidate the cache (bump "v1" -> "v2") without deleting
+	// anything
+	CacheVersion *string `yaml:"cacheVersion,omitempty"`
+}
+
+// ImageConfig is the build configuration for a single image
+type ImageConfig struct {
+	// Tag is the tag to build and push the image as. A random tag is
+	// generated when unset
+	Tag *string `yaml:"tag,omitempty"`
+
+	// Build configures how this image is built
+	Build *ImageBuildConfig `yaml:"build,omitempty"`
+}
+
+// ImageBuildConfig is the per-image build configuration
+type ImageBuildConfig struct {
+	// Disabled skips building this image entirely
+	Disabled *bool `yaml:"disabled,omitempty"`
+
+	// Buildah selects the buildah backend for this image. A non-nil,
+	// zero-value `build.buildah: {}` is enough to select it
+	Buildah *BuildahConfig `yaml:"buildah,omitempty"`
+
+	// CacheRepository is the registry repository the buildah backend pushes
+	// its cache manifests to and checks on ShouldRebuild. Unset disables the
+	// registry cache
+	CacheRepository *string `yaml:"cacheRepository,omitempty"`
+}
+
+// BuildahConfig configures the buildah backend
+type BuildahConfig struct {
+	// Dockerfile overrides image.DefaultDockerfilePath
+	Dockerfile *string `yaml:"dockerfile,omitempty"`
+
+	// Context overrides image.DefaultContextPath
+	Context *string `yaml:"context,omitempty"`
+
+	// Jobs is forwarded to `buildah bud --jobs` for multi-stage parallelism
+	Jobs *int `yaml:"jobs,omitempty"`
+}
@@ -1,17 +1,18 @@
 package image
 
 import (
-	"bytes"
+	"context"
 	"fmt"
+	"runtime"
 
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/devspace-cloud/devspace/pkg/devspace/config/configutil"
 	"github.com/devspace-cloud/devspace/pkg/devspace/config/generated"
+	"github.com/devspace-cloud/devspace/pkg/devspace/config/versions/latest"
 	logpkg "github.com/devspace-cloud/devspace/pkg/util/log"
 	"github.com/devspace-cloud/devspace/pkg/util/randutil"
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
 )
 
 // DefaultDockerfilePath is the default dockerfile path to use
@@ -24,23 +25,30 @@ type imageNameAndTag struct {
 	imageConfigName string
 	imageName       string
 	imageTag        string
+	// stageHash is the builder's cache key at the time of this successful
+	// build, set only when the builder implements CacheKeyer. It is persisted
+	// to the generated cache here, after the build succeeded, rather than by
+	// ShouldRebuild before the build even ran.
+	stageHash string
 }
 
-// BuildAll builds all images
-func BuildAll(client kubernetes.Interface, isDev, forceRebuild, sequential bool, log logpkg.Logger) (map[string]string, error) {
-	var (
-		config      = configutil.GetConfig()
-		builtImages = make(map[string]string)
-
-		// Parallel build
-		errChan   = make(chan error)
-		cacheChan = make(chan imageNameAndTag)
-	)
+// buildJob is a single image queued up to build: everything runBuilds needs
+// to run it, with the devspace-config lookups (ShouldRebuild, tag selection,
+// disabled check) already resolved by BuildAll
+type buildJob struct {
+	imageConfigName string
+	imageTag        string
+	builder         Builder
+}
 
-	// Build not in parallel when we only have one image to build
-	if sequential == false && len(*config.Images) <= 1 {
-		sequential = true
-	}
+// BuildAll builds all images. jobs caps the number of images that are built
+// concurrently: jobs == 1 builds strictly sequentially, jobs == 0 defaults to
+// runtime.NumCPU(), and jobs < 0 means "not set on the CLI", falling back to
+// the devspace.yaml build.jobs setting (and from there to runtime.NumCPU()
+// too). ctx is honored for cancellation: once ctx is done no new builds are
+// started and BuildAll returns ctx.Err() after in-flight builds drain.
+func BuildAll(ctx context.Context, client kubernetes.Interface, isDev, forceRebuild bool, jobs int, log logpkg.Logger) (map[string]string, error) {
+	config := configutil.GetConfig()
 
 	generatedConfig, err := generated.LoadConfig()
 	if err != nil {
@@ -50,8 +58,12 @@ func BuildAll(client kubernetes.Interface, isDev, forceRebuild, sequential bool,
 	// Update config
 	cache := generatedConfig.GetActive()
 
-	imagesToBuild := 0
+	var jobsList []buildJob
 	for imageConfigName, imageConf := range *config.Images {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		if imageConf.Build != nil && imageConf.Build.Disabled != nil && *imageConf.Build.Disabled == true {
 			log.Infof("Skipping building image %s", imageConfigName)
 			continue
@@ -64,7 +76,7 @@ func BuildAll(client kubernetes.Interface, isDev, forceRebuild, sequential bool,
 		builder := newBuilderConfig(client, imageConfigName, &cImageConf, isDev)
 
 		// Check if rebuild is needed
-		needRebuild, err := builder.shouldRebuild(cache)
+		needRebuild, err := builder.ShouldRebuild(ctx, cache)
 		if err != nil {
 			return nil, fmt.Errorf("Error during shouldRebuild check: %v", err)
 		}
@@ -82,67 +94,198 @@ func BuildAll(client kubernetes.Interface, isDev, forceRebuild, sequential bool,
 			imageTag = *imageConf.Tag
 		}
 
-		if sequential {
+		jobsList = append(jobsList, buildJob{imageConfigName: imageConfigName, imageTag: imageTag, builder: builder})
+	}
+
+	results, err := runBuilds(ctx, resolveJobs(jobs, config.Build), jobsList, log)
+	if err != nil {
+		return nil, err
+	}
+
+	builtImages := make(map[string]string, len(results))
+	for _, done := range results {
+		imageCache := cache.GetImageCache(done.imageConfigName)
+		imageCache.ImageName = done.imageName
+		imageCache.Tag = done.imageTag
+		if done.stageHash != "" {
+			imageCache.DockerfileHash = done.stageHash
+		}
+
+		builtImages[done.imageName] = done.imageTag
+	}
+
+	return builtImages, nil
+}
+
+// runBuilds runs every job in jobsList, bounding the number of concurrent
+// builder.Build calls to jobs. It is factored out of BuildAll so the
+// worker-pool/cancellation logic can be exercised directly in tests, without
+// the devspace-config plumbing BuildAll otherwise needs.
+//
+// The acquire select below also has to be able to receive from errChan and
+// doneChan, not just send to sem: both are unbuffered, and a worker's only
+// way to free its sem slot is to finish sending its own result first (see
+// the deferred `<-sem` below). Once the pool saturates (pending == jobs), a
+// worker blocked sending its result would otherwise never be able to unblock
+// a dispatch loop that is itself only trying to send to sem.
+func runBuilds(ctx context.Context, jobs int, jobsList []buildJob, log logpkg.Logger) ([]imageNameAndTag, error) {
+	var (
+		errChan  = make(chan error)
+		doneChan = make(chan imageNameAndTag)
+		sem      = make(chan struct{}, jobs)
+		pending  = 0
+		failed   = false
+		firstErr error
+		results  []imageNameAndTag
+		// ctxDone is nilled out once observed so a canceled ctx doesn't turn the
+		// loops below into busy-spins while workers finish up
+		ctxDone = ctx.Done()
+	)
+
+dispatch:
+	for _, job := range jobsList {
+		if failed {
+			break
+		}
+
+		// Bound the number of in-flight builds to sem's capacity, while still
+		// draining any worker that's already trying to report back so a full
+		// pool can't deadlock against the dispatch loop (see doc comment above).
+		for acquired := false; !acquired; {
+			select {
+			case sem <- struct{}{}:
+				acquired = true
+			case <-ctxDone:
+				failed, firstErr, ctxDone = true, ctx.Err(), nil
+			case err := <-errChan:
+				pending--
+				if failed == false {
+					failed, firstErr = true, err
+				}
+			case done := <-doneChan:
+				pending--
+				results = append(results, done)
+			}
+
+			if failed {
+				break dispatch
+			}
+		}
+
+		pending++
+		job := job
+		go func() {
+			defer func() { <-sem }()
+
+			// Tag every line this build writes with the image config name and
+			// stream it to log as it happens, instead of only revealing output
+			// once the build finishes or fails
+			prefixLog := logpkg.PrefixLogger(log, job.imageConfigName, logpkg.NextPrefixColor())
+
 			// Build the image
-			err = builder.Build(imageTag, log)
-			if err != nil {
-				return nil, err
+			err := job.builder.Build(ctx, job.imageTag, prefixLog)
+
+			// Build writes through prefixLog as a command's stdout/stderr, which
+			// only forwards complete lines live; flush its last, likely
+			// unterminated line now that the build has finished
+			if f, ok := prefixLog.(interface{ Flush() }); ok {
+				f.Flush()
 			}
 
-			// Update cache
-			imageCache := cache.GetImageCache(imageConfigName)
-			imageCache.ImageName = builder.imageName
-			imageCache.Tag = imageTag
-
-			// Track built images
-			builtImages[builder.imageName] = imageTag
-		} else {
-			imagesToBuild++
-			go func() {
-				// Create a string log
-				buff := &bytes.Buffer{}
-				streamLog := logpkg.NewStreamLogger(buff, logrus.InfoLevel)
-
-				// Build the image
-				err := builder.Build(imageTag, streamLog)
-				if err != nil {
-					errChan <- fmt.Errorf("Error building image %s:%s: %s %v", builder.imageName, imageTag, buff.String(), err)
-					return
+			if err != nil {
+				transcript := ""
+				if t, ok := prefixLog.(interface{ Transcript() string }); ok {
+					transcript = t.Transcript()
 				}
 
-				// Send the reponse
-				cacheChan <- imageNameAndTag{
-					imageConfigName: builder.imageConfigName,
-					imageName:       builder.imageName,
-					imageTag:        imageTag,
+				errChan <- fmt.Errorf("Error building image %s:%s: %s %v", job.builder.ImageName(), job.imageTag, transcript, err)
+				return
+			}
+
+			// Only a successful build may advance the cache entry a CacheKeyer
+			// builder uses to decide future rebuilds
+			stageHash := ""
+			if keyer, ok := job.builder.(CacheKeyer); ok {
+				if hash, err := keyer.StageHash(ctx); err == nil {
+					stageHash = hash
 				}
-			}()
+			}
+
+			// Send the reponse
+			doneChan <- imageNameAndTag{
+				imageConfigName: job.builder.ImageConfigName(),
+				imageName:       job.builder.ImageName(),
+				imageTag:        job.imageTag,
+				stageHash:       stageHash,
+			}
+		}()
+
+		// Drain any already finished builds without blocking so that a failure is
+		// noticed as early as possible and stops further images from being started.
+		select {
+		case <-ctxDone:
+			failed, firstErr, ctxDone = true, ctx.Err(), nil
+		case err := <-errChan:
+			failed, firstErr, pending = true, err, pending-1
+		case done := <-doneChan:
+			pending--
+			results = append(results, done)
+		default:
 		}
 	}
 
-	if sequential == false && imagesToBuild > 0 {
-		defer log.StopWait()
-
-		for imagesToBuild > 0 {
-			log.StartWait(fmt.Sprintf("Building %d images...", imagesToBuild))
+	if pending > 0 {
+		if failed == false {
+			log.StartWait(fmt.Sprintf("Building %d images...", pending))
+		}
 
+		for pending > 0 {
 			select {
+			case <-ctxDone:
+				ctxDone = nil
+				if failed == false {
+					failed, firstErr = true, ctx.Err()
+				}
 			case err := <-errChan:
-				return nil, err
-			case done := <-cacheChan:
-				imagesToBuild--
-				log.Donef("Done building image %s (%s:%s)", done.imageConfigName, done.imageName, done.imageTag)
-
-				// Update cache
-				imageCache := cache.GetImageCache(done.imageConfigName)
-				imageCache.ImageName = done.imageName
-				imageCache.Tag = done.imageTag
-
-				// Track built images
-				builtImages[done.imageName] = done.imageTag
+				pending--
+				if failed == false {
+					failed, firstErr = true, err
+				}
+			case done := <-doneChan:
+				pending--
+				if failed == false {
+					log.Donef("Done building image %s (%s:%s)", done.imageConfigName, done.imageName, done.imageTag)
+					results = append(results, done)
+				}
 			}
 		}
+
+		log.StopWait()
 	}
 
-	return builtImages, nil
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return results, nil
+}
+
+// resolveJobs returns the effective worker-pool size for a requested jobs
+// value. jobs < 0 means the CLI's --jobs flag wasn't set, so build.Jobs from
+// the devspace config is consulted instead; jobs == 0 (whether passed
+// directly or read from the config) means "use all CPUs", matching buildah's
+// --jobs semantics.
+func resolveJobs(jobs int, build *latest.BuildConfig) int {
+	if jobs < 0 {
+		jobs = 0
+		if build != nil && build.Jobs != nil {
+			jobs = *build.Jobs
+		}
+	}
+
+	if jobs <= 0 {
+		return runtime.NumCPU()
+	}
+
+	return jobs
 }
@@ -0,0 +1,160 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devspace-cloud/devspace/pkg/devspace/config/generated"
+	logpkg "github.com/devspace-cloud/devspace/pkg/util/log"
+)
+
+// fakeLogger is a no-op logpkg.Logger stand-in for tests that don't care
+// about log output, only about runBuilds' scheduling behavior
+type fakeLogger struct{}
+
+func (fakeLogger) Infof(format string, args ...interface{}) {}
+func (fakeLogger) Donef(format string, args ...interface{}) {}
+func (fakeLogger) Warnf(format string, args ...interface{}) {}
+func (fakeLogger) StartWait(message string)                 {}
+func (fakeLogger) StopWait()                                {}
+func (fakeLogger) Write(p []byte) (int, error)              { return len(p), nil }
+
+var _ logpkg.Logger = fakeLogger{}
+
+// fakeBuilder is a stub image.Builder whose Build just sleeps briefly, so
+// tests can control how many builds are in flight at once without shelling
+// out to a real build tool
+type fakeBuilder struct {
+	name    string
+	delay   time.Duration
+	running *int32
+	maxSeen *int32
+}
+
+func (b *fakeBuilder) Build(ctx context.Context, imageTag string, log logpkg.Logger) error {
+	n := atomic.AddInt32(b.running, 1)
+	for {
+		max := atomic.LoadInt32(b.maxSeen)
+		if n <= max || atomic.CompareAndSwapInt32(b.maxSeen, max, n) {
+			break
+		}
+	}
+	defer atomic.AddInt32(b.running, -1)
+
+	select {
+	case <-time.After(b.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+func (b *fakeBuilder) ShouldRebuild(ctx context.Context, cache *generated.CacheConfig) (bool, error) {
+	return true, nil
+}
+
+func (b *fakeBuilder) ImageName() string       { return b.name }
+func (b *fakeBuilder) ImageConfigName() string { return b.name }
+
+var _ Builder = &fakeBuilder{}
+
+// TestRunBuildsDoesNotDeadlockWhenPoolSaturates is a regression test for a
+// deadlock: with jobs < len(jobsList), the dispatch loop used to block
+// trying to acquire a semaphore slot without listening on errChan/doneChan,
+// while the only way a worker released its slot was by successfully sending
+// its own result on one of those channels first.
+func TestRunBuildsDoesNotDeadlockWhenPoolSaturates(t *testing.T) {
+	const imageCount = 5
+	const jobs = 2
+
+	var running, maxSeen int32
+
+	jobsList := make([]buildJob, 0, imageCount)
+	for i := 0; i < imageCount; i++ {
+		jobsList = append(jobsList, buildJob{
+			imageConfigName: fmt.Sprintf("image-%d", i),
+			imageTag:        "test",
+			builder: &fakeBuilder{
+				name:    fmt.Sprintf("image-%d", i),
+				delay:   20 * time.Millisecond,
+				running: &running,
+				maxSeen: &maxSeen,
+			},
+		})
+	}
+
+	done := make(chan struct{})
+	var results []imageNameAndTag
+	var runErr error
+
+	go func() {
+		defer close(done)
+		results, runErr = runBuilds(context.Background(), jobs, jobsList, fakeLogger{})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runBuilds deadlocked: pool saturated with jobs < len(jobsList) never drained")
+	}
+
+	if runErr != nil {
+		t.Fatalf("runBuilds: %v", runErr)
+	}
+	if len(results) != imageCount {
+		t.Fatalf("expected %d results, got %d", imageCount, len(results))
+	}
+	if atomic.LoadInt32(&maxSeen) > jobs {
+		t.Errorf("expected at most %d concurrent builds, saw %d", jobs, maxSeen)
+	}
+}
+
+// TestRunBuildsStopsOnFirstError checks that a failing build still lets the
+// pool drain instead of hanging, and that the first error is returned.
+func TestRunBuildsStopsOnFirstError(t *testing.T) {
+	var running, maxSeen int32
+
+	jobsList := []buildJob{
+		{imageConfigName: "ok", imageTag: "test", builder: &fakeBuilder{name: "ok", delay: 5 * time.Millisecond, running: &running, maxSeen: &maxSeen}},
+		{imageConfigName: "broken", imageTag: "test", builder: &failingBuilder{name: "broken"}},
+		{imageConfigName: "ok2", imageTag: "test", builder: &fakeBuilder{name: "ok2", delay: 5 * time.Millisecond, running: &running, maxSeen: &maxSeen}},
+	}
+
+	done := make(chan struct{})
+	var runErr error
+	go func() {
+		defer close(done)
+		_, runErr = runBuilds(context.Background(), 1, jobsList, fakeLogger{})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runBuilds deadlocked on a failing build")
+	}
+
+	if runErr == nil {
+		t.Fatal("expected an error from the failing build, got nil")
+	}
+}
+
+type failingBuilder struct {
+	name string
+}
+
+func (b *failingBuilder) Build(ctx context.Context, imageTag string, log logpkg.Logger) error {
+	return fmt.Errorf("boom")
+}
+
+func (b *failingBuilder) ShouldRebuild(ctx context.Context, cache *generated.CacheConfig) (bool, error) {
+	return true, nil
+}
+
+func (b *failingBuilder) ImageName() string       { return b.name }
+func (b *failingBuilder) ImageConfigName() string { return b.name }
+
+var _ Builder = &failingBuilder{}
@@ -0,0 +1,60 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/devspace-cloud/devspace/pkg/devspace/config/versions/latest"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestResolveJobsExplicitValue(t *testing.T) {
+	if got := resolveJobs(4, nil); got != 4 {
+		t.Errorf("expected explicit jobs=4 to pass through, got %d", got)
+	}
+}
+
+func TestResolveJobsZeroMeansAllCPUs(t *testing.T) {
+	got := resolveJobs(0, nil)
+	if got <= 0 {
+		t.Errorf("expected jobs=0 to resolve to a positive CPU count, got %d", got)
+	}
+}
+
+func TestResolveJobsSequential(t *testing.T) {
+	if got := resolveJobs(1, nil); got != 1 {
+		t.Errorf("expected jobs=1 to stay sequential, got %d", got)
+	}
+}
+
+func TestResolveJobsFallsBackToConfigWhenUnset(t *testing.T) {
+	build := &latest.BuildConfig{Jobs: intPtr(3)}
+
+	if got := resolveJobs(-1, build); got != 3 {
+		t.Errorf("expected unset CLI jobs to fall back to build.Jobs=3, got %d", got)
+	}
+}
+
+func TestResolveJobsConfigZeroMeansAllCPUs(t *testing.T) {
+	build := &latest.BuildConfig{Jobs: intPtr(0)}
+
+	got := resolveJobs(-1, build)
+	if got <= 0 {
+		t.Errorf("expected build.Jobs=0 to resolve to a positive CPU count, got %d", got)
+	}
+}
+
+func TestResolveJobsUnsetWithNoConfigMeansAllCPUs(t *testing.T) {
+	got := resolveJobs(-1, nil)
+	if got <= 0 {
+		t.Errorf("expected no config and no CLI flag to resolve to a positive CPU count, got %d", got)
+	}
+}
+
+func TestResolveJobsCLITakesPrecedenceOverConfig(t *testing.T) {
+	build := &latest.BuildConfig{Jobs: intPtr(3)}
+
+	if got := resolveJobs(2, build); got != 2 {
+		t.Errorf("expected explicit CLI jobs=2 to win over build.Jobs=3, got %d", got)
+	}
+}
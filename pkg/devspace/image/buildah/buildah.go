@@ -0,0 +1,219 @@
+// Package buildah implements an image.Builder backend that shells out to the
+// buildah CLI instead of talking to a Docker daemon or an in-cluster kaniko
+// pod. This lets `devspace build` run on rootless CI runners and
+// podman-based workstations that have neither.
+package buildah
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/devspace-cloud/devspace/pkg/devspace/config/generated"
+	"github.com/devspace-cloud/devspace/pkg/devspace/config/versions/latest"
+	"github.com/devspace-cloud/devspace/pkg/devspace/image"
+	"github.com/devspace-cloud/devspace/pkg/devspace/image/registrycache"
+	"github.com/devspace-cloud/devspace/pkg/devspace/image/stages"
+	logpkg "github.com/devspace-cloud/devspace/pkg/util/log"
+	"github.com/pkg/errors"
+)
+
+// Builder builds a single image via `buildah bud`
+type Builder struct {
+	imageConfigName string
+	imageConf       *latest.ImageConfig
+	imageName       string
+	// cacheVersion is devspace.yaml's top-level build.cacheVersion, mixed into
+	// every registry cache key so it can be bumped to invalidate everything
+	cacheVersion string
+}
+
+// NewBuilder creates a new buildah builder for the given image config. It is
+// selected by newBuilderConfig when an image sets `build.buildah: {}`
+func NewBuilder(imageConfigName string, imageConf *latest.ImageConfig, imageName, cacheVersion string) *Builder {
+	return &Builder{
+		imageConfigName: imageConfigName,
+		imageConf:       imageConf,
+		imageName:       imageName,
+		cacheVersion:    cacheVersion,
+	}
+}
+
+// ImageName returns the fully qualified image name
+func (b *Builder) ImageName() string {
+	return b.imageName
+}
+
+// ImageConfigName returns the name this image has in the devspace config
+func (b *Builder) ImageConfigName() string {
+	return b.imageConfigName
+}
+
+// Build runs `buildah bud --isolation=chroot`, which works without a
+// privileged daemon, tags the result imageName:imageTag and pushes it with
+// `buildah push`. On success it
+// records the stage hash in the registry cache, if one is configured, so the
+// next cold run can skip rebuilding entirely
+func (b *Builder) Build(ctx context.Context, imageTag string, log logpkg.Logger) error {
+	dockerfilePath, contextPath := b.dockerfileAndContext()
+
+	args := []string{
+		"bud",
+		"--isolation=chroot",
+		"-f", dockerfilePath,
+		"-t", fmt.Sprintf("%s:%s", b.imageName, imageTag),
+	}
+	if jobs := b.jobs(); jobs > 0 {
+		args = append(args, "--jobs", strconv.Itoa(jobs))
+	}
+	args = append(args, contextPath)
+
+	cmd := exec.CommandContext(ctx, "buildah", args...)
+	cmd.Stdout = log
+	cmd.Stderr = log
+
+	if err := cmd.Run(); err != nil {
+		return errors.Wrap(err, "buildah bud")
+	}
+
+	push := exec.CommandContext(ctx, "buildah", "push", fmt.Sprintf("%s:%s", b.imageName, imageTag))
+	push.Stdout = log
+	push.Stderr = log
+
+	if err := push.Run(); err != nil {
+		return errors.Wrap(err, "buildah push")
+	}
+
+	// Only now that the build actually succeeded is it safe to publish the
+	// registry cache manifest; BuildAll persists the local cache entry itself
+	// via StageHash once it sees this Build call return nil
+	if repository := b.cacheRepository(); repository != "" {
+		hash, hashErr := b.StageHash(ctx)
+		if hashErr != nil {
+			log.Warnf("Skipping registry cache push: %v", hashErr)
+			return nil
+		}
+
+		if err := registrycache.NewCache(repository).Push(ctx, hash, fmt.Sprintf("%s:%s", b.imageName, imageTag)); err != nil {
+			log.Warnf("Push registry cache manifest: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// ShouldRebuild first checks the local generated cache and, if that is
+// stale, falls back to a registry-backed cache lookup before deciding the
+// stage actually needs to be rebuilt. It only reads the cache; the registry
+// cache is only ever advanced by Build and the local generated cache by
+// BuildAll, once a build actually succeeds
+func (b *Builder) ShouldRebuild(ctx context.Context, cache *generated.CacheConfig) (bool, error) {
+	hash, err := b.StageHash(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	imageCache := cache.GetImageCache(b.imageConfigName)
+	if imageCache.DockerfileHash == hash {
+		return false, nil
+	}
+
+	if repository := b.cacheRepository(); repository != "" {
+		hit, err := registrycache.NewCache(repository).Has(ctx, hash)
+		if err == nil && hit {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// StageHash mixes the Dockerfile, the build context, the resolved digest of
+// the final stage's base image and the configured cacheVersion into the
+// registry cache key for this image's (single) stage. Resolving the base
+// image to its current digest (rather than hashing the mutable "golang:1.21"
+// reference literally) is what lets a repushed upstream tag invalidate the
+// cache. BuildAll calls this once more after a successful Build to persist
+// the generated cache entry, so a failed build never advances the cache.
+//
+// When the last stage's FROM continues an earlier stage in the same
+// Dockerfile (e.g. `FROM builder`) rather than an external image, there is no
+// registry reference to resolve a digest for, so digest resolution is
+// skipped entirely for that case instead of firing a HEAD request at a
+// made-up registry path.
+func (b *Builder) StageHash(ctx context.Context) (string, error) {
+	dockerfilePath, contextPath := b.dockerfileAndContext()
+
+	contents, err := ioutil.ReadFile(dockerfilePath)
+	if err != nil {
+		return "", errors.Wrap(err, "read dockerfile")
+	}
+
+	dockerfileSum := sha256.Sum256(contents)
+	dockerfileHash := hex.EncodeToString(dockerfileSum[:])
+
+	contextHash, err := image.HashContext(contextPath)
+	if err != nil {
+		return "", errors.Wrap(err, "hash build context")
+	}
+
+	dag, err := stages.Plan(contents)
+	if err != nil {
+		return "", errors.Wrap(err, "plan dockerfile stages")
+	}
+
+	baseDigest := ""
+	stageIndex := 0
+	if len(dag.Stages) > 0 {
+		last := dag.Stages[len(dag.Stages)-1]
+		stageIndex = last.Index
+
+		if _, internal := dag.StageByRef(last.From); !internal {
+			baseDigest, err = registrycache.ResolveDigest(ctx, nil, last.From)
+			if err != nil {
+				return "", errors.Wrapf(err, "resolve digest of base image %q", last.From)
+			}
+		}
+	}
+
+	return registrycache.Hash(dockerfileHash, contextHash, baseDigest, stageIndex, b.cacheVersion), nil
+}
+
+func (b *Builder) cacheRepository() string {
+	if b.imageConf.Build != nil && b.imageConf.Build.CacheRepository != nil {
+		return *b.imageConf.Build.CacheRepository
+	}
+
+	return ""
+}
+
+func (b *Builder) dockerfileAndContext() (string, string) {
+	dockerfilePath := image.DefaultDockerfilePath
+	contextPath := image.DefaultContextPath
+
+	if b.imageConf.Build != nil && b.imageConf.Build.Buildah != nil {
+		if b.imageConf.Build.Buildah.Dockerfile != nil {
+			dockerfilePath = *b.imageConf.Build.Buildah.Dockerfile
+		}
+		if b.imageConf.Build.Buildah.Context != nil {
+			contextPath = *b.imageConf.Build.Buildah.Context
+		}
+	}
+
+	return filepath.Clean(dockerfilePath), filepath.Clean(contextPath)
+}
+
+func (b *Builder) jobs() int {
+	if b.imageConf.Build != nil && b.imageConf.Build.Buildah != nil && b.imageConf.Build.Buildah.Jobs != nil {
+		return *b.imageConf.Build.Buildah.Jobs
+	}
+
+	return 0
+}
+
+var _ image.Builder = &Builder{}
@@ -0,0 +1,140 @@
+package buildah
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devspace-cloud/devspace/pkg/devspace/config/versions/latest"
+)
+
+func strPtr(v string) *string { return &v }
+func intPtr(v int) *int       { return &v }
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestCacheRepository(t *testing.T) {
+	withRepo := &Builder{imageConf: &latest.ImageConfig{Build: &latest.ImageBuildConfig{CacheRepository: strPtr("my-registry.io/cache")}}}
+	if got := withRepo.cacheRepository(); got != "my-registry.io/cache" {
+		t.Errorf("expected configured cache repository, got %q", got)
+	}
+
+	noBuild := &Builder{imageConf: &latest.ImageConfig{}}
+	if got := noBuild.cacheRepository(); got != "" {
+		t.Errorf("expected empty cache repository when Build is unset, got %q", got)
+	}
+}
+
+func TestDockerfileAndContextDefaults(t *testing.T) {
+	b := &Builder{imageConf: &latest.ImageConfig{}}
+
+	dockerfilePath, contextPath := b.dockerfileAndContext()
+	if dockerfilePath != filepath.Clean("./Dockerfile") {
+		t.Errorf("expected default dockerfile path, got %q", dockerfilePath)
+	}
+	if contextPath != filepath.Clean("./") {
+		t.Errorf("expected default context path, got %q", contextPath)
+	}
+}
+
+func TestDockerfileAndContextOverride(t *testing.T) {
+	b := &Builder{imageConf: &latest.ImageConfig{Build: &latest.ImageBuildConfig{
+		Buildah: &latest.BuildahConfig{
+			Dockerfile: strPtr("docker/Dockerfile.prod"),
+			Context:    strPtr("docker/"),
+		},
+	}}}
+
+	dockerfilePath, contextPath := b.dockerfileAndContext()
+	if dockerfilePath != filepath.Clean("docker/Dockerfile.prod") {
+		t.Errorf("expected overridden dockerfile path, got %q", dockerfilePath)
+	}
+	if contextPath != filepath.Clean("docker/") {
+		t.Errorf("expected overridden context path, got %q", contextPath)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	withJobs := &Builder{imageConf: &latest.ImageConfig{Build: &latest.ImageBuildConfig{
+		Buildah: &latest.BuildahConfig{Jobs: intPtr(4)},
+	}}}
+	if got := withJobs.jobs(); got != 4 {
+		t.Errorf("expected configured jobs=4, got %d", got)
+	}
+
+	noJobs := &Builder{imageConf: &latest.ImageConfig{}}
+	if got := noJobs.jobs(); got != 0 {
+		t.Errorf("expected jobs=0 when unset, got %d", got)
+	}
+}
+
+// TestStageHashSkipsDigestResolutionForInternalStageRef is a regression test:
+// when the last stage's FROM continues an earlier stage in the same
+// Dockerfile, StageHash used to treat it as an external registry reference
+// and fire a HEAD request at a made-up path, erroring out every rebuild
+// check for this very common multi-stage pattern.
+func TestStageHashSkipsDigestResolutionForInternalStageRef(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "Dockerfile"), `
+FROM golang:1.21 AS builder
+RUN go build -o /app ./...
+
+FROM builder AS final
+RUN echo hi
+`)
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+
+	b := &Builder{
+		imageConfigName: "app",
+		imageConf: &latest.ImageConfig{Build: &latest.ImageBuildConfig{
+			Buildah: &latest.BuildahConfig{
+				Dockerfile: strPtr(filepath.Join(dir, "Dockerfile")),
+				Context:    strPtr(dir),
+			},
+		}},
+	}
+
+	hash, err := b.StageHash(context.Background())
+	if err != nil {
+		t.Fatalf("StageHash: %v (should not attempt to resolve a digest for an internal stage reference)", err)
+	}
+	if hash == "" {
+		t.Fatal("expected a non-empty stage hash")
+	}
+}
+
+func TestStageHashIsStableAcrossCalls(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "Dockerfile"), `
+FROM golang:1.21 AS builder
+FROM builder AS final
+`)
+
+	b := &Builder{
+		imageConfigName: "app",
+		imageConf: &latest.ImageConfig{Build: &latest.ImageBuildConfig{
+			Buildah: &latest.BuildahConfig{
+				Dockerfile: strPtr(filepath.Join(dir, "Dockerfile")),
+				Context:    strPtr(dir),
+			},
+		}},
+	}
+
+	a, err := b.StageHash(context.Background())
+	if err != nil {
+		t.Fatalf("StageHash: %v", err)
+	}
+	bHash, err := b.StageHash(context.Background())
+	if err != nil {
+		t.Fatalf("StageHash: %v", err)
+	}
+	if a != bHash {
+		t.Errorf("expected StageHash to be stable across calls, got %q != %q", a, bHash)
+	}
+}
@@ -0,0 +1,39 @@
+package image
+
+import (
+	"context"
+
+	"github.com/devspace-cloud/devspace/pkg/devspace/config/generated"
+	logpkg "github.com/devspace-cloud/devspace/pkg/util/log"
+)
+
+// Builder builds a single image. It is implemented by the docker, kaniko,
+// custom and buildah backends so newBuilderConfig can hand BuildAll a single
+// common type regardless of which backend an image is configured to use
+type Builder interface {
+	// Build builds and pushes the image, tagging it imageTag
+	Build(ctx context.Context, imageTag string, log logpkg.Logger) error
+
+	// ShouldRebuild decides whether the image needs building based on the
+	// generated cache
+	ShouldRebuild(ctx context.Context, cache *generated.CacheConfig) (bool, error)
+
+	// ImageName returns the fully qualified image name (registry + repository)
+	ImageName() string
+
+	// ImageConfigName returns the name this image has in the devspace config
+	ImageConfigName() string
+}
+
+// CacheKeyer is implemented by builders (currently buildah) that key their
+// generated-cache entry off more than just a Dockerfile content hash, e.g.
+// mixing in a resolved base-image digest and a build-context hash. BuildAll
+// calls StageHash once a Build succeeds to persist the cache entry, so a
+// failed build never advances it
+type CacheKeyer interface {
+	// StageHash returns the builder's current cache key. It must be pure and
+	// side-effect free: ShouldRebuild and BuildAll both call it freely to
+	// compare against the generated cache, and only BuildAll writes the
+	// result back, and only after a successful Build
+	StageHash(ctx context.Context) (string, error)
+}
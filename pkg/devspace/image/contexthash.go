@@ -0,0 +1,48 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// HashContext returns a stable hash over the relative paths, sizes and mtimes
+// of every file in a build context, so builders can tell whether a context
+// changed without re-hashing its full contents on every run
+func HashContext(contextPath string) (string, error) {
+	var entries []string
+
+	err := filepath.Walk(contextPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(contextPath, path)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, fmt.Sprintf("%s:%d:%d", rel, info.Size(), info.ModTime().UnixNano()))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(entries)
+
+	hash := sha256.New()
+	for _, entry := range entries {
+		io.WriteString(hash, entry)
+		io.WriteString(hash, "\n")
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,74 @@
+package image
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+func TestHashContextIsStableAcrossRuns(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "Dockerfile", "FROM alpine\n")
+	writeFile(t, dir, "main.go", "package main\n")
+
+	a, err := HashContext(dir)
+	if err != nil {
+		t.Fatalf("HashContext: %v", err)
+	}
+	b, err := HashContext(dir)
+	if err != nil {
+		t.Fatalf("HashContext: %v", err)
+	}
+	if a != b {
+		t.Fatalf("expected HashContext to be stable, got %q != %q", a, b)
+	}
+}
+
+func TestHashContextChangesWithContent(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "main.go", "package main\n")
+
+	before, err := HashContext(dir)
+	if err != nil {
+		t.Fatalf("HashContext: %v", err)
+	}
+
+	writeFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+
+	after, err := HashContext(dir)
+	if err != nil {
+		t.Fatalf("HashContext: %v", err)
+	}
+
+	if before == after {
+		t.Fatal("expected editing a file's content to change its hash")
+	}
+}
+
+func TestHashContextChangesWithNewFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "main.go", "package main\n")
+
+	before, err := HashContext(dir)
+	if err != nil {
+		t.Fatalf("HashContext: %v", err)
+	}
+
+	writeFile(t, dir, "extra.go", "package main\n")
+
+	after, err := HashContext(dir)
+	if err != nil {
+		t.Fatalf("HashContext: %v", err)
+	}
+
+	if before == after {
+		t.Fatal("expected adding a file to change the context hash")
+	}
+}
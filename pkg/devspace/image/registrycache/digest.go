@@ -0,0 +1,70 @@
+package registrycache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ResolveDigest resolves ref (e.g. "golang:1.21" or "my-registry.io/base:v2")
+// to its current registry digest via a manifest HEAD request. This is what
+// lets the stage hash change when a mutable upstream tag is repushed, instead
+// of treating the literal "golang:1.21" string as if it never changes
+func ResolveDigest(ctx context.Context, client *http.Client, ref string) (string, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	repository, tag := splitRef(ref)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL(repository, tag), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json, application/vnd.oci.image.manifest.v1+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolve digest for %q: unexpected status %s", ref, resp.Status)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("resolve digest for %q: registry did not return a Docker-Content-Digest header", ref)
+	}
+
+	return digest, nil
+}
+
+// splitRef splits a "repository[:tag]" reference, defaulting to "latest"
+func splitRef(ref string) (repository, tag string) {
+	idx := strings.LastIndex(ref, ":")
+	if idx < 0 || strings.Contains(ref[idx:], "/") {
+		return ref, "latest"
+	}
+
+	return ref[:idx], ref[idx+1:]
+}
+
+// manifestURL builds the registry v2 manifest URL for repository:tag,
+// defaulting to Docker Hub (and its implicit "library/" namespace) when
+// repository has no explicit registry host
+func manifestURL(repository, tag string) string {
+	host := "registry-1.docker.io"
+	path := repository
+
+	if slash := strings.Index(repository, "/"); slash >= 0 && strings.ContainsAny(repository[:slash], ".:") {
+		host = repository[:slash]
+		path = repository[slash+1:]
+	} else if !strings.Contains(repository, "/") {
+		path = "library/" + repository
+	}
+
+	return fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, path, tag)
+}
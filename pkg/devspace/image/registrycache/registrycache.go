@@ -0,0 +1,94 @@
+// Package registrycache implements a FROM/stage build cache backed by a
+// registry repository instead of the local generated config, so a cold
+// machine or CI runner can skip rebuilding a stage another machine already
+// built and pushed.
+package registrycache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Hash mixes a Dockerfile hash, a build-context hash, the resolved base-image
+// digest, the stage index and the user-controlled cacheVersion knob into a
+// single cache key. Bumping cacheVersion (e.g. "v1" -> "v2") invalidates
+// every stage at once without deleting any cache entries
+func Hash(dockerfileHash, contextHash, baseDigest string, stageIndex int, cacheVersion string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%d|%s", dockerfileHash, contextHash, baseDigest, stageIndex, cacheVersion)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Cache checks and records stage build cache entries in a registry repository
+type Cache struct {
+	// Repository is the registry repository cache manifests are kept in,
+	// e.g. "my-registry.io/project/build-cache"
+	Repository string
+	// Client is used to talk to the registry; defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// NewCache creates a registry-backed stage cache for the given repository
+func NewCache(repository string) *Cache {
+	return &Cache{Repository: repository}
+}
+
+// Has reports whether a cache manifest already exists for stageHash, i.e.
+// whether the stage can be skipped and its remote tag reused
+func (c *Cache) Has(ctx context.Context, stageHash string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.manifestURL(stageHash), nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// Push records imageRef (the stage's just-built image reference) as the
+// cache manifest for stageHash
+func (c *Cache) Push(ctx context.Context, stageHash, imageRef string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.manifestURL(stageHash), strings.NewReader(imageRef))
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("push cache manifest: unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// manifestURL splits Repository into its registry host and repository path;
+// a bare registry host keeps its manifests in the "cache" repository
+func (c *Cache) manifestURL(stageHash string) string {
+	host, path := c.Repository, "cache"
+	if slash := strings.Index(c.Repository, "/"); slash >= 0 {
+		host, path = c.Repository[:slash], c.Repository[slash+1:]
+	}
+
+	return fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, path, stageHash)
+}
+
+func (c *Cache) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+
+	return http.DefaultClient
+}
@@ -0,0 +1,167 @@
+package registrycache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestHashIsDeterministic(t *testing.T) {
+	a := Hash("dfhash", "ctxhash", "sha256:abc", 0, "v1")
+	b := Hash("dfhash", "ctxhash", "sha256:abc", 0, "v1")
+	if a != b {
+		t.Fatalf("expected Hash to be deterministic, got %q != %q", a, b)
+	}
+}
+
+func TestHashChangesWithCacheVersion(t *testing.T) {
+	v1 := Hash("dfhash", "ctxhash", "sha256:abc", 0, "v1")
+	v2 := Hash("dfhash", "ctxhash", "sha256:abc", 0, "v2")
+	if v1 == v2 {
+		t.Fatal("expected bumping cacheVersion to change the hash")
+	}
+}
+
+func TestHashChangesWithStageIndex(t *testing.T) {
+	a := Hash("dfhash", "ctxhash", "sha256:abc", 0, "v1")
+	b := Hash("dfhash", "ctxhash", "sha256:abc", 1, "v1")
+	if a == b {
+		t.Fatal("expected a different stage index to change the hash")
+	}
+}
+
+func TestCacheHasHitAndMiss(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("expected HEAD request, got %s", r.Method)
+		}
+
+		if r.URL.Path == "/v2/cache/manifests/present" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	cache := NewCache(server.Listener.Addr().String())
+	cache.Client = server.Client()
+
+	hit, err := cache.Has(context.Background(), "present")
+	if err != nil {
+		t.Fatalf("Has: %v", err)
+	}
+	if !hit {
+		t.Error("expected a cache hit for the present manifest")
+	}
+
+	hit, err = cache.Has(context.Background(), "absent")
+	if err != nil {
+		t.Fatalf("Has: %v", err)
+	}
+	if hit {
+		t.Error("expected a cache miss for the absent manifest")
+	}
+}
+
+func TestCachePush(t *testing.T) {
+	var gotMethod, gotPath, gotBody string
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotPath = r.URL.Path
+		buf := make([]byte, 64)
+		n, _ := r.Body.Read(buf)
+		gotBody = string(buf[:n])
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	cache := NewCache(server.Listener.Addr().String())
+	cache.Client = server.Client()
+
+	if err := cache.Push(context.Background(), "abc123", "my-registry.io/app:abc123"); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	if gotMethod != http.MethodPut {
+		t.Errorf("expected PUT, got %s", gotMethod)
+	}
+	if gotPath != "/v2/cache/manifests/abc123" {
+		t.Errorf("expected /v2/cache/manifests/abc123, got %s", gotPath)
+	}
+	if gotBody != "my-registry.io/app:abc123" {
+		t.Errorf("expected pushed body to be the image ref, got %q", gotBody)
+	}
+}
+
+// redirectTransport rewrites every request's scheme/host to target, so
+// ResolveDigest's hardcoded "https://registry-1.docker.io/..." URL can be
+// exercised against a local httptest server instead of a real registry.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	req.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestResolveDigestReadsDockerContentDigestHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/library/golang/manifests/1.21" {
+			t.Errorf("unexpected manifest path %q", r.URL.Path)
+		}
+		w.Header().Set("Docker-Content-Digest", "sha256:deadbeef")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	client := &http.Client{Transport: redirectTransport{target: target}}
+
+	digest, err := ResolveDigest(context.Background(), client, "golang:1.21")
+	if err != nil {
+		t.Fatalf("ResolveDigest: %v", err)
+	}
+	if digest != "sha256:deadbeef" {
+		t.Errorf("expected sha256:deadbeef, got %q", digest)
+	}
+}
+
+func TestResolveDigestMissingHeaderIsAnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	client := &http.Client{Transport: redirectTransport{target: target}}
+
+	if _, err := ResolveDigest(context.Background(), client, "golang:1.21"); err == nil {
+		t.Fatal("expected an error when the registry omits Docker-Content-Digest")
+	}
+}
+
+func TestSplitRef(t *testing.T) {
+	tests := []struct {
+		ref, repository, tag string
+	}{
+		{"golang:1.21", "golang", "1.21"},
+		{"golang", "golang", "latest"},
+		{"my-registry.io:5000/app:v2", "my-registry.io:5000/app", "v2"},
+		{"my-registry.io:5000/app", "my-registry.io:5000/app", "latest"},
+	}
+
+	for _, tt := range tests {
+		repository, tag := splitRef(tt.ref)
+		if repository != tt.repository || tag != tt.tag {
+			t.Errorf("splitRef(%q) = (%q, %q), expected (%q, %q)", tt.ref, repository, tag, tt.repository, tt.tag)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// BuildFunc builds a single stage. resolved holds the image IDs of every
+// stage this stage depends on (as already built by a previous wave), so a
+// `COPY --from=<stage>` instruction can be resolved to the just-built
+// intermediate rather than re-pulling a tag
+type BuildFunc func(ctx context.Context, stage *Stage, resolved map[int]string) (imageID string, err error)
+
+// Build walks the DAG in topological waves (see Waves), dispatching every
+// stage in a wave to build concurrently, bounded by jobs, and blocking
+// dependents until their parents' image IDs are resolved. jobs <= 0 means
+// runtime.NumCPU(). It returns the image ID each stage built to, keyed by
+// stage index, and the first error encountered, if any
+func (d *DAG) Build(ctx context.Context, jobs int, build BuildFunc) (map[int]string, error) {
+	waves, err := d.Waves()
+	if err != nil {
+		return nil, err
+	}
+
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	sem := make(chan struct{}, jobs)
+
+	var (
+		mu       sync.Mutex
+		resolved = make(map[int]string, len(d.Stages))
+	)
+
+	for _, wave := range waves {
+		var (
+			wg       sync.WaitGroup
+			firstErr error
+		)
+
+		for _, stage := range wave {
+			stage := stage
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				wg.Wait()
+				return resolved, ctx.Err()
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				mu.Lock()
+				deps := make(map[int]string, len(stage.Dependencies))
+				for _, dep := range stage.Dependencies {
+					deps[dep] = resolved[dep]
+				}
+				mu.Unlock()
+
+				imageID, err := build(ctx, stage, deps)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("build stage %s: %v", stage.Name, err)
+					}
+					return
+				}
+				resolved[stage.Index] = imageID
+			}()
+		}
+
+		wg.Wait()
+		if firstErr != nil {
+			return resolved, firstErr
+		}
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,173 @@
+// Package stages parses a multi-stage Dockerfile into a dependency DAG so
+// independent stages can be scheduled for concurrent building instead of
+// being built one after another.
+package stages
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	fromRegexp     = regexp.MustCompile(`(?i)^FROM\s+(\S+)(?:\s+AS\s+(\S+))?`)
+	copyFromRegexp = regexp.MustCompile(`(?i)--from=(\S+)`)
+)
+
+// Stage is a single `FROM ... [AS <name>]` section of a Dockerfile
+type Stage struct {
+	// Name is the `AS <name>` alias if set, or the stage's numeric index otherwise
+	Name string
+	// Index is the stage's position in the Dockerfile, starting at 0
+	Index int
+	// From is the raw argument of the stage's FROM instruction
+	From string
+	// Lines holds the stage's instructions, FROM included
+	Lines []string
+	// Dependencies are the indices of stages this stage's FROM or `COPY --from`
+	// instructions reference
+	Dependencies []int
+}
+
+// DAG is the dependency graph of a Dockerfile's stages
+type DAG struct {
+	Stages []*Stage
+}
+
+// StageByRef resolves a `FROM`/`--from` reference (a stage name or numeric
+// index) to a stage index. The second return value is false when ref points
+// outside the Dockerfile, i.e. it is an external base image
+func (d *DAG) StageByRef(ref string) (int, bool) {
+	for _, stage := range d.Stages {
+		if strings.EqualFold(stage.Name, ref) {
+			return stage.Index, true
+		}
+	}
+
+	if idx, err := strconv.Atoi(ref); err == nil && idx >= 0 && idx < len(d.Stages) {
+		return idx, true
+	}
+
+	return -1, false
+}
+
+// Waves groups the DAG's stages into topological waves: stages in the same
+// wave have no dependency on each other and can be built concurrently, while
+// a wave can only start once every stage in all previous waves has finished
+func (d *DAG) Waves() ([][]*Stage, error) {
+	remaining := make(map[int][]int, len(d.Stages))
+	for _, stage := range d.Stages {
+		remaining[stage.Index] = append([]int{}, stage.Dependencies...)
+	}
+
+	var waves [][]*Stage
+	for len(remaining) > 0 {
+		var wave []*Stage
+		for _, stage := range d.Stages {
+			deps, ok := remaining[stage.Index]
+			if !ok {
+				continue
+			}
+
+			if len(deps) == 0 {
+				wave = append(wave, stage)
+			}
+		}
+
+		if len(wave) == 0 {
+			return nil, fmt.Errorf("cyclic or unresolved stage dependency detected")
+		}
+
+		for _, stage := range wave {
+			delete(remaining, stage.Index)
+		}
+
+		for idx, deps := range remaining {
+			remaining[idx] = removeResolved(deps, wave)
+		}
+
+		waves = append(waves, wave)
+	}
+
+	return waves, nil
+}
+
+func removeResolved(deps []int, resolved []*Stage) []int {
+	filtered := deps[:0]
+	for _, dep := range deps {
+		resolvedDep := false
+		for _, stage := range resolved {
+			if stage.Index == dep {
+				resolvedDep = true
+				break
+			}
+		}
+		if !resolvedDep {
+			filtered = append(filtered, dep)
+		}
+	}
+	return filtered
+}
+
+// Plan parses a Dockerfile into its stage dependency DAG so that independent
+// stages can be built concurrently instead of strictly in order
+func Plan(dockerfile []byte) (*DAG, error) {
+	dag := &DAG{}
+
+	var current *Stage
+	scanner := bufio.NewScanner(bytes.NewReader(dockerfile))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if match := fromRegexp.FindStringSubmatch(line); match != nil {
+			name := match[2]
+			if name == "" {
+				name = strconv.Itoa(len(dag.Stages))
+			}
+
+			current = &Stage{
+				Name:  name,
+				Index: len(dag.Stages),
+				From:  match[1],
+			}
+			dag.Stages = append(dag.Stages, current)
+
+			if fromIdx, ok := dag.StageByRef(current.From); ok && fromIdx != current.Index {
+				current.Dependencies = append(current.Dependencies, fromIdx)
+			}
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("dockerfile instruction %q found before first FROM", line)
+		}
+		current.Lines = append(current.Lines, line)
+
+		for _, match := range copyFromRegexp.FindAllStringSubmatch(line, -1) {
+			depIdx, ok := dag.StageByRef(match[1])
+			if !ok || depIdx == current.Index || containsInt(current.Dependencies, depIdx) {
+				continue
+			}
+			current.Dependencies = append(current.Dependencies, depIdx)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read dockerfile: %v", err)
+	}
+
+	return dag, nil
+}
+
+func containsInt(haystack []int, needle int) bool {
+	for _, v := range haystack {
+		if v == needle {
+			return true
+		}
+	}
+	return false
+}
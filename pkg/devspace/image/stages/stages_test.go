@@ -0,0 +1,155 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestPlanSingleStage(t *testing.T) {
+	dag, err := Plan([]byte(`
+FROM golang:1.21
+RUN go build ./...
+`))
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	if len(dag.Stages) != 1 {
+		t.Fatalf("expected 1 stage, got %d", len(dag.Stages))
+	}
+	if dag.Stages[0].From != "golang:1.21" {
+		t.Errorf("expected From %q, got %q", "golang:1.21", dag.Stages[0].From)
+	}
+	if len(dag.Stages[0].Dependencies) != 0 {
+		t.Errorf("expected no dependencies, got %v", dag.Stages[0].Dependencies)
+	}
+}
+
+func TestPlanMultiStageDependencies(t *testing.T) {
+	dag, err := Plan([]byte(`
+FROM golang:1.21 AS builder
+RUN go build -o /app ./...
+
+FROM alpine AS final
+COPY --from=builder /app /app
+
+FROM final AS tagged
+RUN echo hi
+`))
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	if len(dag.Stages) != 3 {
+		t.Fatalf("expected 3 stages, got %d", len(dag.Stages))
+	}
+
+	builderIdx, ok := dag.StageByRef("builder")
+	if !ok || builderIdx != 0 {
+		t.Fatalf("expected builder stage at index 0, got %d (ok=%v)", builderIdx, ok)
+	}
+
+	final := dag.Stages[1]
+	if final.Name != "final" {
+		t.Fatalf("expected second stage named final, got %q", final.Name)
+	}
+	if len(final.Dependencies) != 1 || final.Dependencies[0] != builderIdx {
+		t.Errorf("expected final to depend on builder (%d), got %v", builderIdx, final.Dependencies)
+	}
+
+	tagged := dag.Stages[2]
+	if len(tagged.Dependencies) != 1 || tagged.Dependencies[0] != final.Index {
+		t.Errorf("expected tagged to depend on final (%d), got %v", final.Index, tagged.Dependencies)
+	}
+}
+
+func TestPlanInstructionBeforeFrom(t *testing.T) {
+	_, err := Plan([]byte(`RUN echo hi`))
+	if err == nil {
+		t.Fatal("expected error for instruction before first FROM, got nil")
+	}
+}
+
+func TestWavesGroupsIndependentStages(t *testing.T) {
+	dag, err := Plan([]byte(`
+FROM golang:1.21 AS go
+FROM node:20 AS js
+FROM alpine AS final
+COPY --from=go /app /app
+COPY --from=js /dist /dist
+`))
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	waves, err := dag.Waves()
+	if err != nil {
+		t.Fatalf("Waves: %v", err)
+	}
+	if len(waves) != 2 {
+		t.Fatalf("expected 2 waves, got %d", len(waves))
+	}
+	if len(waves[0]) != 2 {
+		t.Errorf("expected first wave to contain the 2 independent stages, got %d", len(waves[0]))
+	}
+	if len(waves[1]) != 1 || waves[1][0].Name != "final" {
+		t.Errorf("expected second wave to be just [final], got %v", waves[1])
+	}
+}
+
+func TestWavesDetectsCycle(t *testing.T) {
+	dag := &DAG{
+		Stages: []*Stage{
+			{Name: "a", Index: 0, Dependencies: []int{1}},
+			{Name: "b", Index: 1, Dependencies: []int{0}},
+		},
+	}
+
+	if _, err := dag.Waves(); err == nil {
+		t.Fatal("expected error for cyclic dependency, got nil")
+	}
+}
+
+func TestDAGBuildResolvesDependencyImageIDs(t *testing.T) {
+	dag, err := Plan([]byte(`
+FROM golang:1.21 AS builder
+FROM alpine AS final
+COPY --from=builder /app /app
+`))
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	results, err := dag.Build(context.Background(), 2, func(ctx context.Context, stage *Stage, resolved map[int]string) (string, error) {
+		if stage.Name == "final" {
+			if resolved[0] != "image-builder" {
+				return "", fmt.Errorf("expected resolved[0] == image-builder, got %q", resolved[0])
+			}
+		}
+		return "image-" + stage.Name, nil
+	})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	if results[0] != "image-builder" || results[1] != "image-final" {
+		t.Errorf("unexpected results: %v", results)
+	}
+}
+
+func TestDAGBuildStopsOnFirstError(t *testing.T) {
+	dag, err := Plan([]byte(`
+FROM golang:1.21 AS builder
+`))
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	_, err = dag.Build(context.Background(), 1, func(ctx context.Context, stage *Stage, resolved map[int]string) (string, error) {
+		return "", fmt.Errorf("boom")
+	})
+	if err == nil {
+		t.Fatal("expected error to propagate from Build, got nil")
+	}
+}
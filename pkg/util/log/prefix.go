@@ -0,0 +1,131 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// prefixColorPalette is assigned round-robin to PrefixLogger instances so
+// that concurrent per-image builds stay visually distinguishable
+var prefixColorPalette = []string{"cyan", "magenta", "yellow", "green", "blue", "red"}
+
+var prefixColorIndex int32
+
+// NextPrefixColor returns the next color in the round-robin palette, for
+// callers that spin up one PrefixLogger per concurrent task
+func NextPrefixColor() string {
+	idx := atomic.AddInt32(&prefixColorIndex, 1) - 1
+	return prefixColorPalette[int(idx)%len(prefixColorPalette)]
+}
+
+var ansiColorCodes = map[string]string{
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+}
+
+func colorize(color, text string) string {
+	code, ok := ansiColorCodes[color]
+	if !ok {
+		return text
+	}
+
+	return fmt.Sprintf("\x1b[%sm%s\x1b[0m", code, text)
+}
+
+// prefixLogger tags every line written to it with a colored prefix and
+// forwards it to a parent Logger as soon as it arrives, instead of buffering
+// output until the task finishes. It embeds Logger so every method it
+// doesn't override is simply forwarded to parent unchanged
+type prefixLogger struct {
+	Logger
+
+	prefix string
+
+	mu         sync.Mutex
+	lineBuf    bytes.Buffer
+	transcript bytes.Buffer
+}
+
+// PrefixLogger returns a Logger that tags every line written to it with
+// "[prefix] " in color and writes it straight through to parent, while still
+// capturing the full transcript so a caller can include it in an error after
+// the fact
+func PrefixLogger(parent Logger, prefix, color string) Logger {
+	return &prefixLogger{
+		Logger: parent,
+		prefix: colorize(color, "["+prefix+"] "),
+	}
+}
+
+// Transcript returns everything written through this logger so far
+func (p *prefixLogger) Transcript() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.transcript.String()
+}
+
+// Flush forwards any remaining partial line in lineBuf to the parent Logger.
+// Write only forwards complete, newline-terminated lines as they arrive, so
+// a command's last line of output is otherwise never forwarded live (though
+// it is always captured in Transcript) when it isn't itself newline-
+// terminated, which most build tools' final line isn't. Callers that use a
+// prefixLogger as a command's stdout/stderr should call Flush once the
+// command has finished.
+func (p *prefixLogger) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.lineBuf.Len() == 0 {
+		return
+	}
+
+	p.Logger.Infof("%s%s", p.prefix, p.lineBuf.String())
+	p.lineBuf.Reset()
+}
+
+// Write implements io.Writer so a prefixLogger can be used directly as a
+// build command's stdout/stderr, splitting the stream into lines so each one
+// can be tagged with the prefix
+func (p *prefixLogger) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.transcript.Write(data)
+	p.lineBuf.Write(data)
+
+	for {
+		buf := p.lineBuf.Bytes()
+		idx := bytes.IndexByte(buf, '\n')
+		if idx < 0 {
+			break
+		}
+
+		line := string(buf[:idx])
+		p.lineBuf.Next(idx + 1)
+		p.Logger.Infof("%s%s", p.prefix, line)
+	}
+
+	return len(data), nil
+}
+
+// Infof tags and forwards a formatted info line
+func (p *prefixLogger) Infof(format string, args ...interface{}) {
+	p.Logger.Infof(p.prefix+format, args...)
+}
+
+// Donef tags and forwards a formatted done line
+func (p *prefixLogger) Donef(format string, args ...interface{}) {
+	p.Logger.Donef(p.prefix+format, args...)
+}
+
+// Warnf tags and forwards a formatted warning line
+func (p *prefixLogger) Warnf(format string, args ...interface{}) {
+	p.Logger.Warnf(p.prefix+format, args...)
+}
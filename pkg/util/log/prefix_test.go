@@ -0,0 +1,103 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+)
+
+// fakeLogger records every Infof call it receives so tests can assert on
+// exactly what a prefixLogger forwarded live
+type fakeLogger struct {
+	infof []string
+}
+
+func (f *fakeLogger) Infof(format string, args ...interface{}) {
+	f.infof = append(f.infof, fmt.Sprintf(format, args...))
+}
+func (f *fakeLogger) Donef(format string, args ...interface{}) {}
+func (f *fakeLogger) Warnf(format string, args ...interface{}) {}
+func (f *fakeLogger) StartWait(message string)                 {}
+func (f *fakeLogger) StopWait()                                {}
+func (f *fakeLogger) Write(p []byte) (int, error)              { return len(p), nil }
+
+var _ Logger = &fakeLogger{}
+
+func newPrefixLogger(parent Logger) *prefixLogger {
+	return PrefixLogger(parent, "app", "").(*prefixLogger)
+}
+
+func TestPrefixLoggerForwardsCompleteLines(t *testing.T) {
+	parent := &fakeLogger{}
+	p := newPrefixLogger(parent)
+
+	if _, err := p.Write([]byte("line one\nline two\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if len(parent.infof) != 2 {
+		t.Fatalf("expected 2 forwarded lines, got %d: %v", len(parent.infof), parent.infof)
+	}
+	if parent.infof[0] != "[app] line one" || parent.infof[1] != "[app] line two" {
+		t.Errorf("unexpected forwarded lines: %v", parent.infof)
+	}
+}
+
+func TestPrefixLoggerDropsTrailingPartialLineUntilFlush(t *testing.T) {
+	parent := &fakeLogger{}
+	p := newPrefixLogger(parent)
+
+	if _, err := p.Write([]byte("complete\nno trailing newline")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if len(parent.infof) != 1 {
+		t.Fatalf("expected only the complete line forwarded before Flush, got %v", parent.infof)
+	}
+
+	p.Flush()
+
+	if len(parent.infof) != 2 {
+		t.Fatalf("expected Flush to forward the trailing partial line, got %v", parent.infof)
+	}
+	if parent.infof[1] != "[app] no trailing newline" {
+		t.Errorf("unexpected flushed line: %q", parent.infof[1])
+	}
+}
+
+func TestPrefixLoggerFlushIsANoOpWhenNothingIsPending(t *testing.T) {
+	parent := &fakeLogger{}
+	p := newPrefixLogger(parent)
+
+	if _, err := p.Write([]byte("complete\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	p.Flush()
+
+	if len(parent.infof) != 1 {
+		t.Fatalf("expected Flush to forward nothing extra when lineBuf is empty, got %v", parent.infof)
+	}
+}
+
+func TestPrefixLoggerTranscriptCapturesEverythingRegardlessOfNewlines(t *testing.T) {
+	parent := &fakeLogger{}
+	p := newPrefixLogger(parent)
+
+	if _, err := p.Write([]byte("complete\nno trailing newline")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if transcript := p.Transcript(); transcript != "complete\nno trailing newline" {
+		t.Errorf("unexpected transcript: %q", transcript)
+	}
+}
+
+func TestNextPrefixColorCyclesThroughThePalette(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < len(prefixColorPalette); i++ {
+		seen[NextPrefixColor()] = true
+	}
+	if len(seen) != len(prefixColorPalette) {
+		t.Errorf("expected %d distinct colors over one full cycle, got %d: %v", len(prefixColorPalette), len(seen), seen)
+	}
+}